@@ -1,16 +1,12 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"flag"
 	"log"
-	"math/rand"
 	"os"
-	"sort"
 
 	"github.com/BurntSushi/toml"
-	"github.com/twilio/twilio-go"
-	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
 )
 
 type Config struct {
@@ -19,7 +15,54 @@ type Config struct {
 		Auth   string `toml:"auth"`
 		Number string `toml:"number"`
 	} `toml:"twilio"`
-	PhoneNumbers []string `toml:"numbers"`
+	// Recipients is a list of typed URIs (e.g. "sms:+15555550100",
+	// "mailto:a@example.com", "telegram:12345") dispatched to the Sender
+	// registered for each scheme. Kept under the legacy "numbers" key for
+	// config compatibility even though it's no longer phone-number-only.
+	Recipients []string `toml:"numbers"`
+	Server     struct {
+		Addr      string `toml:"addr"`
+		PublicURL string `toml:"public_url"`
+	} `toml:"server"`
+	SMTP struct {
+		Host     string `toml:"host"`
+		Port     string `toml:"port"`
+		Username string `toml:"username"`
+		Password string `toml:"password"`
+		From     string `toml:"from"`
+	} `toml:"smtp"`
+	Telegram struct {
+		BotToken string `toml:"bot_token"`
+	} `toml:"telegram"`
+	Matrix struct {
+		Homeserver  string `toml:"homeserver"`
+		AccessToken string `toml:"access_token"`
+	} `toml:"matrix"`
+	Pushover struct {
+		AppToken string `toml:"app_token"`
+	} `toml:"pushover"`
+	Dispatch struct {
+		Workers int     `toml:"workers"`
+		QPS     float64 `toml:"qps"`
+	} `toml:"dispatch"`
+	Store struct {
+		Path string `toml:"path"`
+	} `toml:"store"`
+	// Schedules lets each recipient fire on their own cron expression and
+	// timezone instead of relying on one external cron invoking the binary
+	// for everyone at once.
+	Schedules []ScheduleEntry `toml:"schedule"`
+}
+
+// ScheduleEntry is one [[schedule]] block: a recipient, the cron expression
+// and IANA timezone it should fire on, and an optional quiet-hours window
+// (e.g. "22:00"/"07:00") during which a fire is skipped rather than sent.
+type ScheduleEntry struct {
+	Recipient  string `toml:"recipient"`
+	Cron       string `toml:"cron"`
+	Timezone   string `toml:"timezone"`
+	QuietStart string `toml:"quiet_start"`
+	QuietEnd   string `toml:"quiet_end"`
 }
 
 type BirdWord struct {
@@ -28,11 +71,6 @@ type BirdWord struct {
 	Url  string `json:"species_page"`
 }
 
-type MessageTracker struct {
-	Index map[string]int
-	path  string
-}
-
 func getEnv(key, fallback string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -40,32 +78,6 @@ func getEnv(key, fallback string) string {
 	return fallback
 }
 
-func (mt *MessageTracker) save() error {
-	data, err := json.Marshal(mt.Index)
-	if err != nil {
-		return fmt.Errorf("error marshalling message index: %w", err)
-	}
-	return os.WriteFile(mt.path, data, 0644)
-}
-
-func NewMessageTracker(path string) (*MessageTracker, error) {
-	mt := &MessageTracker{
-		Index: make(map[string]int),
-		path:  path,
-	}
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("error reading message index: %w", err)
-		}
-		return mt, mt.save() // save file if it doesn't exist
-	}
-	if err := json.Unmarshal(data, &mt.Index); err != nil {
-		return nil, fmt.Errorf("error parsing message index: %w", err)
-	}
-	return mt, nil
-}
-
 func loadConfig(path string) (*Config, error) {
 	var config Config
 	if _, err := toml.DecodeFile(path, &config); err != nil {
@@ -74,62 +86,13 @@ func loadConfig(path string) (*Config, error) {
 	return &config, nil
 }
 
-func loadBirdDB(path string) (map[string]BirdWord, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("error reading bird_db.json: %w", err)
-	}
-
-	var birdDb map[string]BirdWord
-	if err := json.Unmarshal(data, &birdDb); err != nil {
-		return nil, fmt.Errorf("error parsing bird_db.json: %w", err)
-	}
-	return birdDb, nil
-}
-
-func getShuffledKeys(birdDb map[string]BirdWord, path string) ([]string, error) {
-	keys := make([]string, 0, len(birdDb))
-	for k := range birdDb {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			return nil, fmt.Errorf("reading shuffled keys: %w", err)
-		}
-		// Create new shuffled keys if file doesn't exist
-		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
-		data, err := json.Marshal(keys)
-		if err != nil {
-			return nil, fmt.Errorf("marshalling shuffled keys: %w", err)
-		}
-		err = os.WriteFile(path, data, 0644)
-		if err != nil {
-			return nil, fmt.Errorf("writing shuffled keys: %w", err)
-		}
-		return keys, nil
-	}
-
-	if err := json.Unmarshal(data, &keys); err != nil {
-		return nil, fmt.Errorf("parsing shuffled keys: %w", err)
-	}
-	return keys, nil
-}
-
-func sendBirdMessage(client *twilio.RestClient, twilioNumber string, phoneNumber string, birdWord BirdWord) error {
-	params := &twilioApi.CreateMessageParams{}
-	params.SetTo(phoneNumber)
-	params.SetFrom(twilioNumber)
-	params.SetBody(fmt.Sprintf("%s\n%s", birdWord.Text, birdWord.Url))
-	params.SetMediaUrl([]string{birdWord.Img})
-
-	_, err := client.Api.CreateMessage(params)
-	return err
-}
-
 func main() {
+	serve := flag.Bool("serve", false, "run as a long-lived HTTP server handling Twilio webhooks instead of sending once and exiting")
+	migrate := flag.Bool("migrate", false, "import the legacy bird_db.json/shuffled_keys.json/message_index.json files into the store and exit")
+	dryRun := flag.Bool("dry-run", false, "with a [[schedule]] configured, log what would be sent and when instead of sending")
+	catchUp := flag.String("catch-up", "skip", "how to handle schedule fires missed while the daemon was down: skip, send-latest, or send-all-missed")
+	flag.Parse()
+
 	// this file must exist
 	config_path := getEnv("BIRD_CONFIG_PATH", "config.toml")
 	config, err := loadConfig(config_path)
@@ -137,42 +100,44 @@ func main() {
 		log.Fatalf("Error reading config.toml: %v", err)
 	}
 
-	// this file must exist too
-	bird_db_path := getEnv("BIRD_DB_PATH", "bird_db.json")
-	birdDb, err := loadBirdDB(bird_db_path)
-	if err != nil {
-		log.Fatalf("Error reading bird_db.json: %v", err)
+	store_path := config.Store.Path
+	if store_path == "" {
+		store_path = getEnv("BIRD_STORE_PATH", "bird.db")
 	}
-
-	keys, err := getShuffledKeys(birdDb, "shuffled_keys.json")
+	store, err := OpenBoltStore(store_path)
 	if err != nil {
-		log.Fatalf("Error getting shuffled keys: %v", err)
+		log.Fatalf("Error opening bird store: %v", err)
 	}
+	defer store.Close()
 
-	// store the key each phone number is on
-	messageTracker, err := NewMessageTracker("message_index.json")
-	if err != nil {
-		log.Fatalf("Error loading message tracker: %v", err)
+	if *migrate {
+		if err := migrateLegacyJSON(store); err != nil {
+			log.Fatalf("Error migrating legacy JSON files: %v", err)
+		}
+		log.Printf("Migrated legacy JSON files into %s", store_path)
+		return
 	}
 
-	client := twilio.NewRestClientWithParams(twilio.ClientParams{
-		Username: config.Twilio.Sid,
-		Password: config.Twilio.Auth,
-	})
-
-	for _, phoneNumber := range config.PhoneNumbers {
-		birdName := keys[messageTracker.Index[phoneNumber]]
-		birdWord := birdDb[birdName]
-		fmt.Printf("Sending message to %s: %s\n%s\n", phoneNumber, birdName, birdWord.Text[:20])
-
-		if err := sendBirdMessage(client, config.Twilio.Number, phoneNumber, birdWord); err != nil {
-			log.Printf("Failed to send message to %s: %v", phoneNumber, err)
-			continue
+	if *serve {
+		addr := config.Server.Addr
+		if addr == "" {
+			addr = ":8080"
 		}
-
-		messageTracker.Index[phoneNumber]++
+		server := NewBirdServer(config, store, config.Server.PublicURL)
+		if err := server.ListenAndServe(addr); err != nil {
+			log.Fatalf("Error running bird webhook server: %v", err)
+		}
+		return
 	}
-	if err := messageTracker.save(); err != nil {
-		log.Fatalf("Error saving message tracker: %v", err)
+
+	if len(config.Schedules) > 0 {
+		scheduler := NewScheduler(config, store, *dryRun, *catchUp)
+		if err := scheduler.Start(context.Background()); err != nil {
+			log.Fatalf("Error running scheduler: %v", err)
+		}
+		return
 	}
+
+	dispatcher := NewDispatcher(config, store)
+	dispatcher.Run(context.Background(), config.Recipients)
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/textproto"
+	"sync"
+	"time"
+
+	twilioClient "github.com/twilio/twilio-go/client"
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultWorkers   = 4
+	defaultQPS       = 1
+	maxSendAttempts  = 4
+	baseRetryBackoff = 500 * time.Millisecond
+)
+
+// retryableTwilioCodes are the Twilio error codes worth retrying: rate
+// limiting (20429) and queue/channel overload (30001, 30002) are transient
+// and usually succeed on a subsequent attempt.
+var retryableTwilioCodes = map[int]bool{
+	20429: true,
+	30001: true,
+	30002: true,
+}
+
+// isRetryable reports whether err is a Twilio error code known to be
+// transient, an HTTP 429/5xx from one of the postForm-based Sender
+// backends (Telegram, Matrix, Pushover), or a 4xx SMTP temporary-failure
+// reply.
+func isRetryable(err error) bool {
+	var twilioErr *twilioClient.TwilioRestError
+	if errors.As(err, &twilioErr) {
+		if retryableTwilioCodes[twilioErr.Code] {
+			return true
+		}
+		return twilioErr.Status == 429 || twilioErr.Status >= 500
+	}
+
+	var httpErr *httpStatusError
+	if errors.As(err, &httpErr) {
+		return httpErr.statusCode == 429 || httpErr.statusCode >= 500
+	}
+
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		return smtpErr.Code >= 400 && smtpErr.Code < 500
+	}
+
+	return false
+}
+
+// sendWithRetry calls sender.Send, retrying with exponential backoff on
+// retryable errors up to maxSendAttempts times, and returns the backend's
+// message ID on success. The context governs cancellation both between and
+// during attempts.
+func sendWithRetry(ctx context.Context, sender Sender, address string, bw BirdWord) (string, error) {
+	var lastErr error
+	for attempt := 0; attempt < maxSendAttempts; attempt++ {
+		if attempt > 0 {
+			backoff := time.Duration(math.Pow(2, float64(attempt-1))) * baseRetryBackoff
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+
+		var messageID string
+		messageID, lastErr = sender.Send(ctx, address, bw)
+		if lastErr == nil {
+			return messageID, nil
+		}
+		if !isRetryable(lastErr) {
+			return "", lastErr
+		}
+	}
+	return "", fmt.Errorf("giving up after %d attempts: %w", maxSendAttempts, lastErr)
+}
+
+// Dispatcher fans the daily send-out across a worker pool, rate-limited to
+// respect each backend's messages-per-second ceiling, so one slow or
+// throttled recipient doesn't stall the rest and a failure for one
+// recipient never affects another.
+type Dispatcher struct {
+	config  *Config
+	store   Store
+	limiter *rate.Limiter
+	workers int
+}
+
+func NewDispatcher(config *Config, store Store) *Dispatcher {
+	workers := config.Dispatch.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	qps := config.Dispatch.QPS
+	if qps <= 0 {
+		qps = defaultQPS
+	}
+
+	return &Dispatcher{
+		config:  config,
+		store:   store,
+		limiter: rate.NewLimiter(rate.Limit(qps), workers),
+		workers: workers,
+	}
+}
+
+// Run sends the next bird to every eligible recipient concurrently and
+// returns once all of them have been attempted.
+func (d *Dispatcher) Run(ctx context.Context, recipients []string) {
+	jobs := make(chan string)
+
+	var wg sync.WaitGroup
+	for i := 0; i < d.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for recipient := range jobs {
+				d.sendOne(ctx, recipient)
+			}
+		}()
+	}
+
+	for _, recipient := range recipients {
+		sub, err := d.store.Subscription(recipient)
+		if err != nil {
+			log.Printf("Failed to load subscription for %s: %v", recipient, err)
+			continue
+		}
+		if sub.OptedOut || sub.Paused {
+			continue
+		}
+		jobs <- recipient
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func (d *Dispatcher) sendOne(ctx context.Context, recipient string) {
+	if err := d.limiter.Wait(ctx); err != nil {
+		log.Printf("Failed to send message to %s: %v", recipient, err)
+		return
+	}
+
+	sender, address, err := senderFor(d.config, recipient)
+	if err != nil {
+		log.Printf("Failed to resolve sender for %s: %v", recipient, err)
+		return
+	}
+
+	birdName, birdWord, err := d.store.NextBird(recipient)
+	if err != nil {
+		log.Printf("Failed to look up next bird for %s: %v", recipient, err)
+		return
+	}
+
+	messageID, err := sendWithRetry(ctx, sender, address, birdWord)
+	if err != nil {
+		log.Printf("Failed to send message to %s: %v", recipient, err)
+		return
+	}
+
+	if err := d.store.AckSent(recipient, birdName, messageID); err != nil {
+		log.Printf("Failed to record sent message for %s: %v", recipient, err)
+	}
+}
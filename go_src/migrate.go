@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+)
+
+// legacyMessageTrackerFile is the on-disk shape message_index.json used
+// before the bolt store: a bare index map, or (briefly) a wrapper that also
+// carried subscription and delivery-status data.
+type legacyMessageTrackerFile struct {
+	Index         map[string]int           `json:"index"`
+	Subscriptions map[string]*Subscription `json:"subscriptions"`
+	Statuses      map[string]MessageStatus `json:"statuses"`
+}
+
+func loadLegacyBirdDB(path string) (map[string]BirdWord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bird_db.json: %w", err)
+	}
+
+	var birdDb map[string]BirdWord
+	if err := json.Unmarshal(data, &birdDb); err != nil {
+		return nil, fmt.Errorf("error parsing bird_db.json: %w", err)
+	}
+	return birdDb, nil
+}
+
+func loadLegacyShuffledKeys(birdDb map[string]BirdWord, path string) ([]string, error) {
+	keys := make([]string, 0, len(birdDb))
+	for k := range birdDb {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("reading shuffled keys: %w", err)
+		}
+		rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		return keys, nil
+	}
+
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("parsing shuffled keys: %w", err)
+	}
+	return keys, nil
+}
+
+func loadLegacyMessageTracker(path string) (legacyMessageTrackerFile, error) {
+	var file legacyMessageTrackerFile
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return file, nil
+		}
+		return file, fmt.Errorf("error reading message index: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &file); err == nil && file.Index != nil {
+		return file, nil
+	}
+
+	// Fall back to the legacy-legacy format: message_index.json used to be
+	// a bare map[string]int with no subscription or status data at all.
+	if err := json.Unmarshal(data, &file.Index); err != nil {
+		return file, fmt.Errorf("error parsing message index: %w", err)
+	}
+	return file, nil
+}
+
+// migrateLegacyJSON reads bird_db.json, shuffled_keys.json, and
+// message_index.json (using the BIRD_DB_PATH env var override, as the
+// one-shot sender historically did) and imports them into store. It's meant
+// to be run via `-migrate` before switching a deployment over to the
+// store-backed server and dispatcher, and is safe to re-run after a
+// transient failure: progress is imported via SetProgress, which sets each
+// recipient's absolute position rather than advancing it, so repeating the
+// same import is a no-op instead of double-advancing the rotation.
+func migrateLegacyJSON(store *BoltStore) error {
+	birdDbPath := getEnv("BIRD_DB_PATH", "bird_db.json")
+	birdDb, err := loadLegacyBirdDB(birdDbPath)
+	if err != nil {
+		return err
+	}
+
+	keys, err := loadLegacyShuffledKeys(birdDb, "shuffled_keys.json")
+	if err != nil {
+		return err
+	}
+
+	if err := store.SeedBirds(birdDb, keys); err != nil {
+		return fmt.Errorf("seeding bird catalog: %w", err)
+	}
+
+	tracker, err := loadLegacyMessageTracker("message_index.json")
+	if err != nil {
+		return err
+	}
+
+	for recipient, index := range tracker.Index {
+		if err := store.SetProgress(recipient, index); err != nil {
+			return fmt.Errorf("importing progress for %s: %w", recipient, err)
+		}
+	}
+	for recipient, sub := range tracker.Subscriptions {
+		if sub.OptedOut {
+			if err := store.Unsubscribe(recipient); err != nil {
+				return fmt.Errorf("importing opt-out for %s: %w", recipient, err)
+			}
+		}
+		if sub.Paused {
+			if err := store.Pause(recipient); err != nil {
+				return fmt.Errorf("importing pause for %s: %w", recipient, err)
+			}
+		}
+	}
+	for messageSID, status := range tracker.Statuses {
+		if err := store.RecordStatus(messageSID, status.Status, status.ErrorCode); err != nil {
+			return fmt.Errorf("importing status for %s: %w", messageSID, err)
+		}
+	}
+
+	return nil
+}
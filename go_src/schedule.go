@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// catchUpPolicy controls what the scheduler does for a recipient whose
+// schedule fired one or more times while the daemon was down.
+type catchUpPolicy string
+
+const (
+	catchUpSkip          catchUpPolicy = "skip"
+	catchUpSendLatest    catchUpPolicy = "send-latest"
+	catchUpSendAllMissed catchUpPolicy = "send-all-missed"
+
+	// maxCatchUpFires caps how many missed fires send-all-missed will
+	// replay in one burst, so a long outage can't flood a recipient.
+	maxCatchUpFires = 30
+
+	// maxCatchUpCount bounds how far back catchUpMissed will count missed
+	// fires. It's only a safety valve against pathological schedules (e.g.
+	// a per-minute cron down for years); it's well above maxCatchUpFires
+	// so the true missed count can still be reported when replay is capped.
+	maxCatchUpCount = 100000
+)
+
+// Scheduler runs one cron job per [[schedule]] entry instead of relying on
+// an external cron invoking the binary for every recipient at once.
+type Scheduler struct {
+	config   *Config
+	store    Store
+	cron     *cron.Cron
+	dispatch func(ctx context.Context, recipient string) error
+	dryRun   bool
+	catchUp  catchUpPolicy
+}
+
+func NewScheduler(config *Config, store Store, dryRun bool, catchUp string) *Scheduler {
+	policy := catchUpPolicy(catchUp)
+	if policy == "" {
+		policy = catchUpSkip
+	}
+
+	s := &Scheduler{
+		config:  config,
+		store:   store,
+		cron:    cron.New(),
+		dryRun:  dryRun,
+		catchUp: policy,
+	}
+	s.dispatch = s.sendToRecipient
+	return s
+}
+
+// Start registers every schedule entry, applies the catch-up policy for any
+// that were missed while the daemon was down, then runs until ctx is
+// cancelled.
+func (s *Scheduler) Start(ctx context.Context) error {
+	for _, entry := range s.config.Schedules {
+		entry := entry
+		schedule, err := cron.ParseStandard(entry.Cron)
+		if err != nil {
+			return fmt.Errorf("parsing cron expression %q for %s: %w", entry.Cron, entry.Recipient, err)
+		}
+
+		loc, err := scheduleLocation(entry.Timezone)
+		if err != nil {
+			return fmt.Errorf("loading timezone %q for %s: %w", entry.Timezone, entry.Recipient, err)
+		}
+
+		s.catchUpMissed(ctx, entry, schedule, loc)
+
+		spec := entry.Cron
+		if entry.Timezone != "" {
+			spec = fmt.Sprintf("CRON_TZ=%s %s", entry.Timezone, entry.Cron)
+		}
+		if _, err := s.cron.AddFunc(spec, func() { s.fire(ctx, entry, loc) }); err != nil {
+			return fmt.Errorf("scheduling %s: %w", entry.Recipient, err)
+		}
+	}
+
+	s.cron.Start()
+	<-ctx.Done()
+	<-s.cron.Stop().Done()
+	return nil
+}
+
+func scheduleLocation(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(timezone)
+}
+
+// catchUpMissed looks at how many times entry's schedule should have fired
+// since its last recorded run and replays that backlog according to the
+// configured catch-up policy.
+func (s *Scheduler) catchUpMissed(ctx context.Context, entry ScheduleEntry, schedule cron.Schedule, loc *time.Location) {
+	lastRun, err := s.store.LastRun(entry.Recipient)
+	if err != nil {
+		log.Printf("Failed to load last run for %s: %v", entry.Recipient, err)
+		return
+	}
+	if lastRun.IsZero() {
+		return // first time this recipient has ever been scheduled
+	}
+
+	now := time.Now().In(loc)
+	missed := 0
+	for next := schedule.Next(lastRun); !next.After(now) && missed < maxCatchUpCount; next = schedule.Next(next) {
+		missed++
+	}
+	if missed == 0 {
+		return
+	}
+
+	replay := missed
+	if replay > maxCatchUpFires {
+		replay = maxCatchUpFires
+	}
+	truncated := missed > replay
+
+	switch s.catchUp {
+	case catchUpSkip:
+		log.Printf("Skipping %d missed send(s) for %s (catch-up policy: skip)", missed, entry.Recipient)
+	case catchUpSendLatest:
+		if truncated {
+			log.Printf("Sending 1 catch-up bird to %s for %d missed send(s) (capped from %d actually missed)", entry.Recipient, replay, missed)
+		} else {
+			log.Printf("Sending 1 catch-up bird to %s for %d missed send(s)", entry.Recipient, missed)
+		}
+		s.fire(ctx, entry, loc)
+	case catchUpSendAllMissed:
+		if truncated {
+			log.Printf("Sending %d catch-up bird(s) to %s (capped from %d actually missed)", replay, entry.Recipient, missed)
+		} else {
+			log.Printf("Sending %d catch-up bird(s) to %s", replay, entry.Recipient)
+		}
+		for i := 0; i < replay; i++ {
+			s.fire(ctx, entry, loc)
+		}
+	default:
+		log.Printf("Unknown catch-up policy %q for %s, skipping", s.catchUp, entry.Recipient)
+	}
+}
+
+// inQuietHours reports whether now falls inside the [start, end) window,
+// given as "HH:MM" in the schedule's own timezone. A window that wraps past
+// midnight (e.g. 22:00-07:00) is handled by treating end as past midnight.
+func inQuietHours(now time.Time, start, end string) bool {
+	if start == "" || end == "" {
+		return false
+	}
+
+	startT, err := time.Parse("15:04", start)
+	if err != nil {
+		return false
+	}
+	endT, err := time.Parse("15:04", end)
+	if err != nil {
+		return false
+	}
+
+	minutesNow := now.Hour()*60 + now.Minute()
+	minutesStart := startT.Hour()*60 + startT.Minute()
+	minutesEnd := endT.Hour()*60 + endT.Minute()
+
+	if minutesStart <= minutesEnd {
+		return minutesNow >= minutesStart && minutesNow < minutesEnd
+	}
+	// Overnight window, e.g. 22:00-07:00.
+	return minutesNow >= minutesStart || minutesNow < minutesEnd
+}
+
+func (s *Scheduler) fire(ctx context.Context, entry ScheduleEntry, loc *time.Location) {
+	now := time.Now().In(loc)
+	if inQuietHours(now, entry.QuietStart, entry.QuietEnd) {
+		log.Printf("Skipping %s: inside quiet hours (%s-%s %s)", entry.Recipient, entry.QuietStart, entry.QuietEnd, entry.Timezone)
+		return
+	}
+
+	if s.dryRun {
+		birdName, _, err := s.store.NextBird(entry.Recipient)
+		if err != nil {
+			log.Printf("dry-run: failed to look up next bird for %s: %v", entry.Recipient, err)
+			return
+		}
+		log.Printf("dry-run: would send %s to %s at %s", birdName, entry.Recipient, now.Format(time.RFC3339))
+		return
+	}
+
+	if err := s.dispatch(ctx, entry.Recipient); err != nil {
+		log.Printf("Failed to send scheduled bird to %s: %v", entry.Recipient, err)
+		return
+	}
+
+	if err := s.store.SetLastRun(entry.Recipient, time.Now()); err != nil {
+		log.Printf("Failed to record last run for %s: %v", entry.Recipient, err)
+	}
+}
+
+// sendToRecipient resolves the recipient's sender and sends them whatever
+// bird the store currently has queued up for them.
+func (s *Scheduler) sendToRecipient(ctx context.Context, recipient string) error {
+	sub, err := s.store.Subscription(recipient)
+	if err != nil {
+		return fmt.Errorf("loading subscription: %w", err)
+	}
+	if sub.OptedOut || sub.Paused {
+		return nil
+	}
+
+	sender, address, err := senderFor(s.config, recipient)
+	if err != nil {
+		return fmt.Errorf("resolving sender: %w", err)
+	}
+
+	birdName, birdWord, err := s.store.NextBird(recipient)
+	if err != nil {
+		return fmt.Errorf("looking up next bird: %w", err)
+	}
+
+	messageID, err := sendWithRetry(ctx, sender, address, birdWord)
+	if err != nil {
+		return err
+	}
+
+	return s.store.AckSent(recipient, birdName, messageID)
+}
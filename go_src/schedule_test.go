@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInQuietHours(t *testing.T) {
+	day := func(hour, minute int) time.Time {
+		return time.Date(2024, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name         string
+		now          time.Time
+		start, end   string
+		wantInWindow bool
+	}{
+		{"no window configured", day(3, 0), "", "", false},
+		{"same-day window, inside", day(13, 30), "12:00", "14:00", true},
+		{"same-day window, before start", day(11, 59), "12:00", "14:00", false},
+		{"same-day window, at end boundary", day(14, 0), "12:00", "14:00", false},
+		{"overnight window, late night", day(23, 0), "22:00", "07:00", true},
+		{"overnight window, early morning", day(3, 0), "22:00", "07:00", true},
+		{"overnight window, at start boundary", day(22, 0), "22:00", "07:00", true},
+		{"overnight window, at end boundary", day(7, 0), "22:00", "07:00", false},
+		{"overnight window, daytime outside window", day(12, 0), "22:00", "07:00", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := inQuietHours(c.now, c.start, c.end)
+			if got != c.wantInWindow {
+				t.Errorf("inQuietHours(%s, %q, %q) = %v, want %v", c.now.Format("15:04"), c.start, c.end, got, c.wantInWindow)
+			}
+		})
+	}
+}
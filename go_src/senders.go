@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/smtp"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/twilio/twilio-go"
+	twilioApi "github.com/twilio/twilio-go/rest/api/v2010"
+)
+
+// Sender delivers a single bird-of-the-day message to a recipient. Recipients
+// are typed URIs (e.g. "sms:+15555550100", "mailto:a@example.com") so the
+// registry can dispatch to the right backend from the scheme alone. Send
+// returns the backend's own message identifier (Twilio's MessageSid,
+// Telegram's message_id, a Matrix event_id, ...) so a later delivery-status
+// callback can be correlated back to the bird it was sent for; backends
+// with no such concept (SMTP) return "".
+type Sender interface {
+	Send(ctx context.Context, recipient string, bw BirdWord) (messageID string, err error)
+}
+
+// senderRegistry maps a recipient URI scheme to the Sender that handles it.
+var senderRegistry = map[string]func(*Config) (Sender, error){
+	"sms":      newSMSSender,
+	"mailto":   newSMTPSender,
+	"telegram": newTelegramSender,
+	"matrix":   newMatrixSender,
+	"pushover": newPushoverSender,
+}
+
+// senderFor parses recipient as a URI and returns the Sender registered for
+// its scheme, constructed from the matching config section.
+func senderFor(config *Config, recipient string) (Sender, string, error) {
+	u, err := url.Parse(recipient)
+	if err != nil {
+		return nil, "", fmt.Errorf("parsing recipient %q: %w", recipient, err)
+	}
+
+	factory, ok := senderRegistry[u.Scheme]
+	if !ok {
+		return nil, "", fmt.Errorf("no sender registered for scheme %q", u.Scheme)
+	}
+
+	sender, err := factory(config)
+	if err != nil {
+		return nil, "", fmt.Errorf("building %s sender: %w", u.Scheme, err)
+	}
+	return sender, strings.TrimPrefix(recipient, u.Scheme+":"), nil
+}
+
+// SMSSender delivers the bird image and fact via Twilio SMS/MMS. It wraps
+// the same client and request shape the original one-shot sender used.
+type SMSSender struct {
+	client       *twilio.RestClient
+	twilioNumber string
+}
+
+func newSMSSender(config *Config) (Sender, error) {
+	return &SMSSender{
+		client: twilio.NewRestClientWithParams(twilio.ClientParams{
+			Username: config.Twilio.Sid,
+			Password: config.Twilio.Auth,
+		}),
+		twilioNumber: config.Twilio.Number,
+	}, nil
+}
+
+func (s *SMSSender) Send(ctx context.Context, recipient string, bw BirdWord) (string, error) {
+	params := &twilioApi.CreateMessageParams{}
+	params.SetTo(recipient)
+	params.SetFrom(s.twilioNumber)
+	params.SetBody(fmt.Sprintf("%s\n%s", bw.Text, bw.Url))
+	params.SetMediaUrl([]string{bw.Img})
+
+	resp, err := s.client.Api.CreateMessage(params)
+	if err != nil {
+		return "", err
+	}
+	if resp.Sid == nil {
+		return "", nil
+	}
+	return *resp.Sid, nil
+}
+
+// SMTPSender emails the bird fact with the bird image attached inline.
+type SMTPSender struct {
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+func newSMTPSender(config *Config) (Sender, error) {
+	return &SMTPSender{
+		host:     config.SMTP.Host,
+		port:     config.SMTP.Port,
+		username: config.SMTP.Username,
+		password: config.SMTP.Password,
+		from:     config.SMTP.From,
+	}, nil
+}
+
+func (s *SMTPSender) Send(ctx context.Context, recipient string, bw BirdWord) (string, error) {
+	if _, err := mail.ParseAddress(recipient); err != nil {
+		return "", fmt.Errorf("invalid email recipient %q: %w", recipient, err)
+	}
+
+	img, err := fetchURL(ctx, bw.Img)
+	if err != nil {
+		return "", fmt.Errorf("fetching bird image: %w", err)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	textPart, err := writer.CreatePart(map[string][]string{"Content-Type": {"text/plain; charset=UTF-8"}})
+	if err != nil {
+		return "", err
+	}
+	fmt.Fprintf(textPart, "%s\n%s\n", bw.Text, bw.Url)
+
+	imgPart, err := writer.CreatePart(map[string][]string{
+		"Content-Type":              {"image/jpeg"},
+		"Content-Disposition":       {"inline; filename=\"bird.jpg\""},
+		"Content-Transfer-Encoding": {"binary"},
+	})
+	if err != nil {
+		return "", err
+	}
+	if _, err := imgPart.Write(img); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: Bird of the day\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%s\r\n\r\n%s",
+		s.from, recipient, writer.Boundary(), body.String())
+
+	auth := smtp.PlainAuth("", s.username, s.password, s.host)
+	if err := smtp.SendMail(s.host+":"+s.port, auth, s.from, []string{recipient}, []byte(msg)); err != nil {
+		return "", err
+	}
+	// SMTP has no equivalent of a provider message ID to correlate a later
+	// delivery-status callback against.
+	return "", nil
+}
+
+// TelegramSender posts the bird image via the Bot API's sendPhoto method.
+type TelegramSender struct {
+	botToken string
+}
+
+func newTelegramSender(config *Config) (Sender, error) {
+	return &TelegramSender{botToken: config.Telegram.BotToken}, nil
+}
+
+// telegramResponse is the relevant subset of the Bot API's sendPhoto
+// response, used to recover the message_id for later correlation.
+type telegramResponse struct {
+	Result struct {
+		MessageID int `json:"message_id"`
+	} `json:"result"`
+}
+
+func (t *TelegramSender) Send(ctx context.Context, recipient string, bw BirdWord) (string, error) {
+	form := url.Values{}
+	form.Set("chat_id", recipient)
+	form.Set("photo", bw.Img)
+	form.Set("caption", fmt.Sprintf("%s\n%s", bw.Text, bw.Url))
+
+	endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/sendPhoto", t.botToken)
+	body, err := postForm(ctx, endpoint, form)
+	if err != nil {
+		return "", err
+	}
+
+	var resp telegramResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", nil
+	}
+	return fmt.Sprintf("%d", resp.Result.MessageID), nil
+}
+
+// MatrixSender sends an m.image event followed by an m.text event to a
+// Matrix room via the client-server API.
+type MatrixSender struct {
+	homeserver  string
+	accessToken string
+}
+
+func newMatrixSender(config *Config) (Sender, error) {
+	return &MatrixSender{
+		homeserver:  config.Matrix.Homeserver,
+		accessToken: config.Matrix.AccessToken,
+	}, nil
+}
+
+// matrixSendResponse is the relevant subset of the client-server send
+// endpoint's response, used to recover the event_id for later correlation.
+type matrixSendResponse struct {
+	EventID string `json:"event_id"`
+}
+
+func (m *MatrixSender) Send(ctx context.Context, recipient string, bw BirdWord) (string, error) {
+	imageEvent := map[string]any{
+		"msgtype": "m.image",
+		"url":     bw.Img,
+		"body":    "bird.jpg",
+	}
+	if _, err := m.sendEvent(ctx, recipient, imageEvent); err != nil {
+		return "", fmt.Errorf("sending m.image event: %w", err)
+	}
+
+	textEvent := map[string]any{
+		"msgtype": "m.text",
+		"body":    fmt.Sprintf("%s\n%s", bw.Text, bw.Url),
+	}
+	eventID, err := m.sendEvent(ctx, recipient, textEvent)
+	if err != nil {
+		return "", fmt.Errorf("sending m.text event: %w", err)
+	}
+	return eventID, nil
+}
+
+func (m *MatrixSender) sendEvent(ctx context.Context, roomID string, event map[string]any) (string, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+
+	// The Matrix client-server send endpoint is PUT .../send/{eventType}/{txnId};
+	// the txnId lets the homeserver de-duplicate retried requests.
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", m.homeserver, url.PathEscape(roomID), newTxnID())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+m.accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", &httpStatusError{endpoint: endpoint, statusCode: resp.StatusCode, status: resp.Status}
+	}
+
+	respBody := new(bytes.Buffer)
+	if _, err := respBody.ReadFrom(resp.Body); err != nil {
+		return "", err
+	}
+
+	var parsed matrixSendResponse
+	if err := json.Unmarshal(respBody.Bytes(), &parsed); err != nil {
+		return "", nil
+	}
+	return parsed.EventID, nil
+}
+
+// newTxnID generates a unique transaction ID for a Matrix send request.
+func newTxnID() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(buf)
+}
+
+// PushoverSender delivers the bird fact and image as a Pushover notification.
+type PushoverSender struct {
+	appToken string
+}
+
+func newPushoverSender(config *Config) (Sender, error) {
+	return &PushoverSender{appToken: config.Pushover.AppToken}, nil
+}
+
+// pushoverResponse is the relevant subset of the Pushover API's response,
+// used to recover the request id for later correlation.
+type pushoverResponse struct {
+	Request string `json:"request"`
+}
+
+func (p *PushoverSender) Send(ctx context.Context, recipient string, bw BirdWord) (string, error) {
+	form := url.Values{}
+	form.Set("token", p.appToken)
+	form.Set("user", recipient)
+	form.Set("message", fmt.Sprintf("%s\n%s", bw.Text, bw.Url))
+	form.Set("url", bw.Img)
+
+	body, err := postForm(ctx, "https://api.pushover.net/1/messages.json", form)
+	if err != nil {
+		return "", err
+	}
+
+	var resp pushoverResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", nil
+	}
+	return resp.Request, nil
+}
+
+// httpStatusError carries the HTTP status code a backend returned, so
+// callers like isRetryable can tell a transient 429/5xx from a permanent
+// 4xx without re-parsing the error string.
+type httpStatusError struct {
+	endpoint   string
+	statusCode int
+	status     string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s returned %s", e.endpoint, e.status)
+}
+
+// postForm submits form and returns the response body so callers can pull a
+// provider message ID out of it.
+func postForm(ctx context.Context, endpoint string, form url.Values) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body := new(bytes.Buffer)
+	if _, err := body.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, &httpStatusError{endpoint: endpoint, statusCode: resp.StatusCode, status: resp.Status}
+	}
+	return body.Bytes(), nil
+}
+
+func fetchURL(ctx context.Context, target string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	buf := new(bytes.Buffer)
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,397 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketBirds         = []byte("birds")
+	bucketKeys          = []byte("keys")
+	bucketProgress      = []byte("progress")
+	bucketSubscriptions = []byte("subscriptions")
+	bucketStatuses      = []byte("statuses")
+	bucketLastRun       = []byte("lastrun")
+	bucketMessages      = []byte("messages")
+
+	keysListKey = []byte("shuffled")
+)
+
+// Store is the persistence layer for the bird catalog and all per-recipient
+// state: where they are in the rotation, whether they've opted out or
+// paused, and the delivery status of messages we've sent them. It replaces
+// the bird_db.json / shuffled_keys.json / message_index.json trio with a
+// single embedded database so concurrent runs and the webhook server can't
+// race on os.WriteFile.
+type Store interface {
+	// NextBird returns the species key and bird recipient is currently on.
+	// The key is threaded back into AckSent so the store can record what
+	// was actually sent without a second lookup.
+	NextBird(recipient string) (string, BirdWord, error)
+	// AckSent advances recipient past birdKey after messageSID was
+	// confirmed delivered. When messageSID is non-empty (the backend
+	// returned one), it's recorded so a later /status callback for that
+	// SID can be traced back to the recipient and bird it was sent for.
+	AckSent(recipient, birdKey, messageSID string) error
+	// RecordStatus records a delivery status callback for messageSID.
+	RecordStatus(messageSID, status, errorCode string) error
+	// SentMessage looks up the recipient and bird a previously recorded
+	// messageSID was sent for.
+	SentMessage(messageSID string) (SentMessage, error)
+	// Subscribe and Unsubscribe opt a recipient back in or out entirely.
+	Subscribe(recipient string) error
+	Unsubscribe(recipient string) error
+	// Pause and Resume suspend or restore delivery without losing the
+	// recipient's place in the rotation.
+	Pause(recipient string) error
+	Resume(recipient string) error
+	// Advance moves recipient's position in the rotation by delta,
+	// wrapping in both directions; used for the PREV/NEXT SMS commands.
+	Advance(recipient string, delta int) error
+	// SetProgress sets recipient's absolute position in the rotation. Unlike
+	// Advance, repeating the same call is a no-op rather than cumulative;
+	// used by the migration command so re-running -migrate can't
+	// double-advance a recipient that was already imported.
+	SetProgress(recipient string, index int) error
+	// JumpTo moves recipient directly to birdKey; used for the
+	// jump-to-species-name SMS command.
+	JumpTo(recipient, birdKey string) error
+	// Subscription returns the current opt-out/pause state for recipient.
+	Subscription(recipient string) (Subscription, error)
+	// Keys returns the full shuffled species rotation.
+	Keys() ([]string, error)
+	// LastRun and SetLastRun track when a recipient's schedule last fired,
+	// so the daemon can detect and apply a catch-up policy after downtime.
+	LastRun(recipient string) (time.Time, error)
+	SetLastRun(recipient string, at time.Time) error
+	Close() error
+}
+
+// BoltStore is the default Store implementation, backed by a bbolt file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens (creating if necessary) a bbolt database at path with
+// the buckets this package needs.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt store at %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketBirds, bucketKeys, bucketProgress, bucketSubscriptions, bucketStatuses, bucketLastRun, bucketMessages} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("creating bucket %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// SeedBirds loads the species catalog into the store, overwriting whatever
+// was there before. Used by the migration command and by tests.
+func (s *BoltStore) SeedBirds(birdDb map[string]BirdWord, keys []string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		birds := tx.Bucket(bucketBirds)
+		for name, bw := range birdDb {
+			data, err := json.Marshal(bw)
+			if err != nil {
+				return fmt.Errorf("marshalling bird %s: %w", name, err)
+			}
+			if err := birds.Put([]byte(name), data); err != nil {
+				return err
+			}
+		}
+
+		keysData, err := json.Marshal(keys)
+		if err != nil {
+			return fmt.Errorf("marshalling shuffled keys: %w", err)
+		}
+		return tx.Bucket(bucketKeys).Put(keysListKey, keysData)
+	})
+}
+
+func (s *BoltStore) Keys() ([]string, error) {
+	var keys []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketKeys).Get(keysListKey)
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &keys)
+	})
+	return keys, err
+}
+
+func progressValue(index int) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(index))
+	return buf
+}
+
+func parseProgress(data []byte) int {
+	if data == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint64(data))
+}
+
+func (s *BoltStore) NextBird(recipient string) (string, BirdWord, error) {
+	var bw BirdWord
+	var birdName string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		keysData := tx.Bucket(bucketKeys).Get(keysListKey)
+		var keys []string
+		if keysData != nil {
+			if err := json.Unmarshal(keysData, &keys); err != nil {
+				return fmt.Errorf("parsing shuffled keys: %w", err)
+			}
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("no birds loaded in store")
+		}
+
+		index := parseProgress(tx.Bucket(bucketProgress).Get([]byte(recipient))) % len(keys)
+		birdName = keys[index]
+
+		data := tx.Bucket(bucketBirds).Get([]byte(birdName))
+		if data == nil {
+			return fmt.Errorf("bird %s not found in store", birdName)
+		}
+		return json.Unmarshal(data, &bw)
+	})
+	return birdName, bw, err
+}
+
+// SentMessage records which recipient and bird a messageSID was sent for, so
+// a later /status callback for that SID can be traced back to them.
+type SentMessage struct {
+	Recipient string `json:"recipient"`
+	BirdKey   string `json:"bird_key"`
+}
+
+// AckSent advances recipient past birdKey specifically, not past whatever
+// the progress bucket currently holds: NextBird and AckSent straddle the
+// network call to actually send the message, during which a PREV/NEXT/jump
+// SMS command can land and move progress out from under us. Keying the
+// advance off birdKey (rather than re-reading the live index) keeps that
+// race from silently discarding the webhook's effect or double-advancing
+// past a bird nobody was ever told was sent.
+func (s *BoltStore) AckSent(recipient, birdKey, messageSID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		keysData := tx.Bucket(bucketKeys).Get(keysListKey)
+		var keys []string
+		if keysData != nil {
+			if err := json.Unmarshal(keysData, &keys); err != nil {
+				return err
+			}
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("no birds loaded in store")
+		}
+
+		sentIndex := -1
+		for i, key := range keys {
+			if key == birdKey {
+				sentIndex = i
+				break
+			}
+		}
+		if sentIndex == -1 {
+			return fmt.Errorf("sent bird %q not found in store", birdKey)
+		}
+
+		progress := tx.Bucket(bucketProgress)
+		if err := progress.Put([]byte(recipient), progressValue((sentIndex+1)%len(keys))); err != nil {
+			return err
+		}
+
+		if messageSID == "" {
+			return nil
+		}
+		data, err := json.Marshal(SentMessage{Recipient: recipient, BirdKey: birdKey})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketMessages).Put([]byte(messageSID), data)
+	})
+}
+
+func (s *BoltStore) RecordStatus(messageSID, status, errorCode string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(MessageStatus{Status: status, ErrorCode: errorCode})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketStatuses).Put([]byte(messageSID), data)
+	})
+}
+
+func (s *BoltStore) SentMessage(messageSID string) (SentMessage, error) {
+	var sent SentMessage
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketMessages).Get([]byte(messageSID))
+		if data == nil {
+			return fmt.Errorf("no message recorded for SID %s", messageSID)
+		}
+		return json.Unmarshal(data, &sent)
+	})
+	return sent, err
+}
+
+func (s *BoltStore) Subscription(recipient string) (Subscription, error) {
+	var sub Subscription
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketSubscriptions).Get([]byte(recipient))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, &sub)
+	})
+	return sub, err
+}
+
+func (s *BoltStore) putSubscription(recipient string, mutate func(*Subscription)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketSubscriptions)
+		var sub Subscription
+		if data := bucket.Get([]byte(recipient)); data != nil {
+			if err := json.Unmarshal(data, &sub); err != nil {
+				return err
+			}
+		}
+		mutate(&sub)
+		data, err := json.Marshal(sub)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(recipient), data)
+	})
+}
+
+func (s *BoltStore) Subscribe(recipient string) error {
+	return s.putSubscription(recipient, func(sub *Subscription) {
+		sub.OptedOut = false
+		sub.Paused = false
+	})
+}
+
+func (s *BoltStore) Unsubscribe(recipient string) error {
+	return s.putSubscription(recipient, func(sub *Subscription) {
+		sub.OptedOut = true
+	})
+}
+
+func (s *BoltStore) Pause(recipient string) error {
+	return s.putSubscription(recipient, func(sub *Subscription) {
+		sub.Paused = true
+	})
+}
+
+func (s *BoltStore) Resume(recipient string) error {
+	return s.putSubscription(recipient, func(sub *Subscription) {
+		sub.OptedOut = false
+		sub.Paused = false
+	})
+}
+
+func (s *BoltStore) Advance(recipient string, delta int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		keysData := tx.Bucket(bucketKeys).Get(keysListKey)
+		var keys []string
+		if keysData != nil {
+			if err := json.Unmarshal(keysData, &keys); err != nil {
+				return err
+			}
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("no birds loaded in store")
+		}
+
+		progress := tx.Bucket(bucketProgress)
+		index := parseProgress(progress.Get([]byte(recipient)))
+		next := (index + delta) % len(keys)
+		if next < 0 {
+			next += len(keys)
+		}
+		return progress.Put([]byte(recipient), progressValue(next))
+	})
+}
+
+func (s *BoltStore) SetProgress(recipient string, index int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		keysData := tx.Bucket(bucketKeys).Get(keysListKey)
+		var keys []string
+		if keysData != nil {
+			if err := json.Unmarshal(keysData, &keys); err != nil {
+				return err
+			}
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("no birds loaded in store")
+		}
+
+		next := index % len(keys)
+		if next < 0 {
+			next += len(keys)
+		}
+		return tx.Bucket(bucketProgress).Put([]byte(recipient), progressValue(next))
+	})
+}
+
+func (s *BoltStore) LastRun(recipient string) (time.Time, error) {
+	var at time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketLastRun).Get([]byte(recipient))
+		if data == nil {
+			return nil
+		}
+		parsed, err := time.Parse(time.RFC3339, string(data))
+		if err != nil {
+			return fmt.Errorf("parsing last run time for %s: %w", recipient, err)
+		}
+		at = parsed
+		return nil
+	})
+	return at, err
+}
+
+func (s *BoltStore) SetLastRun(recipient string, at time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketLastRun).Put([]byte(recipient), []byte(at.Format(time.RFC3339)))
+	})
+}
+
+func (s *BoltStore) JumpTo(recipient, birdKey string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		keysData := tx.Bucket(bucketKeys).Get(keysListKey)
+		var keys []string
+		if keysData != nil {
+			if err := json.Unmarshal(keysData, &keys); err != nil {
+				return err
+			}
+		}
+		for i, key := range keys {
+			if key == birdKey {
+				return tx.Bucket(bucketProgress).Put([]byte(recipient), progressValue(i))
+			}
+		}
+		return fmt.Errorf("species %q not found", birdKey)
+	})
+}
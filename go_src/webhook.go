@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Subscription tracks the two-way state a recipient can put themselves in by
+// replying to an inbound SMS: STOP opts them out entirely, PAUSE/RESUME
+// toggles delivery without losing their place in the shuffled key list.
+type Subscription struct {
+	OptedOut bool `json:"opted_out"`
+	Paused   bool `json:"paused"`
+}
+
+// MessageStatus records the most recent Twilio delivery callback for a
+// message we sent, keyed by MessageSID.
+type MessageStatus struct {
+	Status    string `json:"status"`
+	ErrorCode string `json:"error_code"`
+}
+
+// validateTwilioSignature checks the X-Twilio-Signature header against the
+// HMAC-SHA1 digest Twilio computes over the full request URL with the sorted
+// POST params appended, as documented for their webhook security model.
+func validateTwilioSignature(authToken, url string, params map[string][]string, signature string) bool {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf strings.Builder
+	buf.WriteString(url)
+	for _, k := range keys {
+		for _, v := range params[k] {
+			buf.WriteString(k)
+			buf.WriteString(v)
+		}
+	}
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(buf.String()))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// BirdServer holds the shared state the webhook handlers need to answer
+// inbound SMS commands and delivery status callbacks.
+type BirdServer struct {
+	config    *Config
+	store     Store
+	publicURL string
+}
+
+func NewBirdServer(config *Config, store Store, publicURL string) *BirdServer {
+	return &BirdServer{
+		config:    config,
+		store:     store,
+		publicURL: publicURL,
+	}
+}
+
+func twiml(message string) string {
+	if message == "" {
+		return `<?xml version="1.0" encoding="UTF-8"?><Response></Response>`
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><Response><Message>%s</Message></Response>`, message)
+}
+
+func (s *BirdServer) verifySignature(r *http.Request) bool {
+	if err := r.ParseForm(); err != nil {
+		return false
+	}
+	return validateTwilioSignature(s.config.Twilio.Auth, s.publicURL+r.URL.Path, r.PostForm, r.Header.Get("X-Twilio-Signature"))
+}
+
+// handleSMS implements the inbound SMS command webhook: POST /sms.
+func (s *BirdServer) handleSMS(w http.ResponseWriter, r *http.Request) {
+	if !s.verifySignature(r) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	from := r.PostFormValue("From")
+	body := strings.TrimSpace(r.PostFormValue("Body"))
+
+	reply, err := s.applyCommand(from, body)
+	if err != nil {
+		log.Printf("error applying SMS command from %s: %v", from, err)
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprint(w, twiml(reply))
+}
+
+// applyCommand applies the recipient's reply to the store and returns the
+// TwiML-safe reply text.
+func (s *BirdServer) applyCommand(from, body string) (string, error) {
+	switch strings.ToUpper(body) {
+	case "STOP":
+		return "You've been unsubscribed from the daily bird. Reply RESUME to opt back in.", s.store.Unsubscribe(from)
+	case "PAUSE":
+		return "Daily bird paused. Reply RESUME to pick back up where you left off.", s.store.Pause(from)
+	case "RESUME":
+		return "Welcome back! Your daily bird will resume on the next send.", s.store.Resume(from)
+	case "PREV":
+		return "Rewound to the previous bird.", s.store.Advance(from, -1)
+	case "NEXT":
+		return "Skipped ahead to the next bird.", s.store.Advance(from, 1)
+	default:
+		species, err := s.findSpecies(body)
+		if err != nil {
+			return "", err
+		}
+		if species == "" {
+			return "Sorry, I didn't recognize that. Reply STOP, PAUSE, RESUME, PREV, NEXT, or a species name.", nil
+		}
+		if err := s.store.JumpTo(from, species); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Jumped to %s. You'll get it on the next send.", species), nil
+	}
+}
+
+// findSpecies does a case-insensitive match of body against the store's
+// species rotation and returns the canonical key, or "" if nothing matches.
+func (s *BirdServer) findSpecies(body string) (string, error) {
+	if body == "" {
+		return "", nil
+	}
+	keys, err := s.store.Keys()
+	if err != nil {
+		return "", fmt.Errorf("loading species keys: %w", err)
+	}
+	for _, key := range keys {
+		if strings.EqualFold(key, body) {
+			return key, nil
+		}
+	}
+	return "", nil
+}
+
+// handleStatus implements the delivery status callback webhook: POST /status.
+func (s *BirdServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.verifySignature(r) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	sid := r.PostFormValue("MessageSid")
+	status := r.PostFormValue("MessageStatus")
+	errorCode := r.PostFormValue("ErrorCode")
+
+	if err := s.store.RecordStatus(sid, status, errorCode); err != nil {
+		log.Printf("error recording status callback for %s: %v", sid, err)
+	}
+
+	if sent, err := s.store.SentMessage(sid); err == nil {
+		log.Printf("status %s (error %s) for %s's %s", status, errorCode, sent.Recipient, sent.BirdKey)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListenAndServe registers the webhook routes and blocks serving on addr.
+func (s *BirdServer) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sms", s.handleSMS)
+	mux.HandleFunc("/status", s.handleStatus)
+	log.Printf("bird webhook server listening on %s", addr)
+	return http.ListenAndServe(addr, mux)
+}
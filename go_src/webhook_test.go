@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestValidateTwilioSignature(t *testing.T) {
+	authToken := "test_auth_token"
+	url := "https://example.com/sms"
+	params := map[string][]string{
+		"From": {"+15551234567"},
+		"Body": {"STOP"},
+		"To":   {"+15557654321"},
+	}
+	// Computed independently via Python's hmac/hashlib against the same
+	// authToken/url/params to pin the HMAC construction Twilio's SDK uses.
+	validSignature := "3oAg1nyxJQRHBrW5Nce3XZhV7gI="
+
+	if !validateTwilioSignature(authToken, url, params, validSignature) {
+		t.Fatal("expected valid signature to verify")
+	}
+
+	if validateTwilioSignature(authToken, url, params, "invalidsignature==") {
+		t.Fatal("expected garbage signature to fail")
+	}
+
+	if validateTwilioSignature("wrong_token", url, params, validSignature) {
+		t.Fatal("expected signature to fail with the wrong auth token")
+	}
+
+	if validateTwilioSignature(authToken, "https://example.com/status", params, validSignature) {
+		t.Fatal("expected signature to fail against a different URL")
+	}
+
+	tamperedParams := map[string][]string{
+		"From": {"+15551234567"},
+		"Body": {"RESUME"},
+		"To":   {"+15557654321"},
+	}
+	if validateTwilioSignature(authToken, url, tamperedParams, validSignature) {
+		t.Fatal("expected signature to fail against tampered params")
+	}
+}